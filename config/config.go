@@ -0,0 +1,132 @@
+// Copyright 2015 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// TargetGroup is a set of targets with a common label set (production,
+// test, staging etc.).
+type TargetGroup struct {
+	// Targets is a list of targets identified by a label set. Each target is
+	// uniquely identifiable in the group by its address label.
+	Targets []model.LabelSet
+	// Labels is a set of labels that is common across all targets in the group.
+	Labels model.LabelSet
+
+	// Source is an identifier that describes a group of targets.
+	Source string
+}
+
+// DefaultEC2SDConfig is the default EC2 SD configuration.
+var DefaultEC2SDConfig = EC2SDConfig{
+	Port:            80,
+	RefreshInterval: model.Duration(60 * time.Second),
+	RoleDuration:    model.Duration(15 * time.Minute),
+}
+
+// EC2SDConfig is the configuration for EC2 based service discovery.
+type EC2SDConfig struct {
+	Region    string `yaml:"region"`
+	AccessKey string `yaml:"access_key,omitempty"`
+	SecretKey string `yaml:"secret_key,omitempty"`
+	// Profile is the name of a shared AWS config/credentials profile to use
+	// instead of AccessKey/SecretKey or the default credential chain.
+	Profile string `yaml:"profile,omitempty"`
+	// RoleARN, if set, is assumed via STS before talking to the EC2 API.
+	// This allows a single Prometheus process to discover targets across
+	// multiple AWS accounts.
+	RoleARN         string         `yaml:"role_arn,omitempty"`
+	ExternalID      string         `yaml:"external_id,omitempty"`
+	RoleSessionName string         `yaml:"role_session_name,omitempty"`
+	RoleDuration    model.Duration `yaml:"role_duration,omitempty"`
+
+	RefreshInterval model.Duration `yaml:"refresh_interval,omitempty"`
+	Port            int            `yaml:"port"`
+
+	// TagFilters is kept for backwards compatibility; each entry is translated
+	// into an EC2 tag filter alongside Filters when the discovery is built
+	// (Filters itself is not changed at load time). New configurations should
+	// use Filters directly.
+	TagFilters []string `yaml:"tag_filters,omitempty"`
+
+	// Filters restricts the discovered instances to those matching all of
+	// the given EC2 filters. Each entry maps directly onto an ec2.Filter,
+	// e.g. {Name: "vpc-id", Values: ["vpc-1234"]}.
+	Filters []*EC2Filter `yaml:"filters,omitempty"`
+
+	// PerInterface, if true, emits one target per network interface instead
+	// of collapsing multi-homed instances onto their primary private IP.
+	PerInterface bool `yaml:"per_interface,omitempty"`
+}
+
+// EC2Filter is the configuration for filtering EC2 instances.
+type EC2Filter struct {
+	Name   string   `yaml:"name"`
+	Values []string `yaml:"values"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *EC2SDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultEC2SDConfig
+	type plain EC2SDConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	for _, f := range c.Filters {
+		if err := ValidateEC2Filter(f.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ec2ValidFilterNames are the EC2 DescribeInstances filter names Prometheus
+// allows in an EC2SDConfig. Arbitrary "tag:*" and "tag-key"/"tag-value"
+// filters are always allowed since their suffix is user-defined.
+var ec2ValidFilterNames = map[string]bool{
+	"affinity":                    true,
+	"architecture":                true,
+	"availability-zone":           true,
+	"image-id":                    true,
+	"instance-state-name":         true,
+	"instance-type":               true,
+	"instance.group-id":           true,
+	"instance.group-name":         true,
+	"network-interface.subnet-id": true,
+	"owner-id":                    true,
+	"platform":                    true,
+	"private-ip-address":          true,
+	"subnet-id":                   true,
+	"tag-key":                     true,
+	"tag-value":                   true,
+	"vpc-id":                      true,
+}
+
+// ValidateEC2Filter reports whether name is a filter Prometheus supports for
+// EC2 service discovery.
+func ValidateEC2Filter(name string) error {
+	if strings.HasPrefix(name, "tag:") {
+		return nil
+	}
+	if !ec2ValidFilterNames[name] {
+		return fmt.Errorf("invalid EC2 SD filter name %q", name)
+	}
+	return nil
+}
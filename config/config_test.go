@@ -0,0 +1,59 @@
+// Copyright 2015 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestEC2SDConfigUnmarshalRejectsInvalidFilter(t *testing.T) {
+	var c EC2SDConfig
+	in := "region: us-east-1\nfilters:\n- name: vpc_id\n  values: ['vpc-1234']\n"
+	if err := yaml.Unmarshal([]byte(in), &c); err == nil {
+		t.Fatal("expected an error for an invalid EC2 SD filter name, got nil")
+	}
+}
+
+func TestEC2SDConfigUnmarshalAcceptsKnownAndTagFilters(t *testing.T) {
+	var c EC2SDConfig
+	in := "region: us-east-1\nfilters:\n- name: vpc-id\n  values: ['vpc-1234']\n- name: tag:Name\n  values: ['web']\n"
+	if err := yaml.Unmarshal([]byte(in), &c); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(c.Filters) != 2 {
+		t.Fatalf("expected 2 filters, got %d", len(c.Filters))
+	}
+}
+
+func TestValidateEC2Filter(t *testing.T) {
+	cases := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"vpc-id", false},
+		{"instance-state-name", false},
+		{"tag-key", false},
+		{"tag:Name", false},
+		{"vpc_id", true},
+		{"bogus-filter", true},
+	}
+	for _, c := range cases {
+		err := ValidateEC2Filter(c.name)
+		if (err != nil) != c.wantErr {
+			t.Errorf("ValidateEC2Filter(%q) error = %v, wantErr %v", c.name, err, c.wantErr)
+		}
+	}
+}
@@ -20,27 +20,43 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/prometheus/common/log"
 	"github.com/prometheus/common/model"
 	"golang.org/x/net/context"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/util/strutil"
 )
 
 const (
-	ec2Label           = model.MetaLabelPrefix + "ec2_"
-	ec2LabelAZ         = ec2Label + "availability_zone"
-	ec2LabelInstanceID = ec2Label + "instance_id"
-	ec2LabelPublicDNS  = ec2Label + "public_dns_name"
-	ec2LabelPublicIP   = ec2Label + "public_ip"
-	ec2LabelPrivateIP  = ec2Label + "private_ip"
-	ec2LabelSubnetID   = ec2Label + "subnet_id"
-	ec2LabelTag        = ec2Label + "tag_"
-	ec2LabelVPCID      = ec2Label + "vpc_id"
-	subnetSeparator    = ","
+	ec2Label                  = model.MetaLabelPrefix + "ec2_"
+	ec2LabelAZ                = ec2Label + "availability_zone"
+	ec2LabelInstanceID        = ec2Label + "instance_id"
+	ec2LabelInstanceState     = ec2Label + "instance_state"
+	ec2LabelInstanceType      = ec2Label + "instance_type"
+	ec2LabelInstanceLifecycle = ec2Label + "instance_lifecycle"
+	ec2LabelAMI               = ec2Label + "ami"
+	ec2LabelArch              = ec2Label + "architecture"
+	ec2LabelPlatform          = ec2Label + "platform"
+	ec2LabelOwnerID           = ec2Label + "owner_id"
+	ec2LabelPrimarySubnetID   = ec2Label + "primary_subnet_id"
+	ec2LabelPublicDNS         = ec2Label + "public_dns_name"
+	ec2LabelPublicIP          = ec2Label + "public_ip"
+	ec2LabelPrivateIP         = ec2Label + "private_ip"
+	ec2LabelSubnetID          = ec2Label + "subnet_id"
+	ec2LabelTag               = ec2Label + "tag_"
+	ec2LabelVPCID             = ec2Label + "vpc_id"
+	ec2LabelENIID             = ec2Label + "eni_id"
+	ec2LabelENISubnetID       = ec2Label + "eni_subnet_id"
+	ec2LabelENIPrivateIP      = ec2Label + "eni_private_ip"
+	ec2LabelENIIPv6Addresses  = ec2Label + "eni_ipv6_addresses"
+	ec2LabelENIPrimary        = ec2Label + "eni_primary"
+	subnetSeparator           = ","
 )
 
 // EC2Discovery periodically performs EC2-SD requests. It implements
@@ -50,14 +66,28 @@ type EC2Discovery struct {
 	interval        time.Duration
 	port            int
 	ec2RequestInput *ec2.DescribeInstancesInput
+	perInterface    bool
 }
 
 // NewEC2Discovery returns a new EC2Discovery which periodically refreshes its targets.
 func NewEC2Discovery(conf *config.EC2SDConfig) *EC2Discovery {
-	creds := credentials.NewStaticCredentials(conf.AccessKey, conf.SecretKey, "")
-	if conf.AccessKey == "" && conf.SecretKey == "" {
-		creds = defaults.DefaultChainCredentials
+	creds := ec2BaseCredentials(conf)
+
+	// If a role ARN was given, assume it via STS and use the resulting
+	// temporary credentials instead, so a single Prometheus process can
+	// discover targets across multiple AWS accounts.
+	if conf.RoleARN != "" {
+		sess, err := session.NewSession(&aws.Config{
+			Region:      &conf.Region,
+			Credentials: creds,
+		})
+		if err != nil {
+			log.Errorf("could not create AWS session to assume role %q: %s", conf.RoleARN, err)
+		} else {
+			creds = credentials.NewCredentials(ec2AssumeRoleProvider(sess, conf))
+		}
 	}
+
 	return &EC2Discovery{
 		aws: &aws.Config{
 			Region:      &conf.Region,
@@ -65,21 +95,64 @@ func NewEC2Discovery(conf *config.EC2SDConfig) *EC2Discovery {
 		},
 		interval:        time.Duration(conf.RefreshInterval),
 		port:            conf.Port,
-		ec2RequestInput: buildEc2RequestInput(conf.TagFilters),
+		ec2RequestInput: buildEc2RequestInput(conf.Filters, conf.TagFilters),
+		perInterface:    conf.PerInterface,
+	}
+}
+
+// ec2BaseCredentials resolves the base AWS credentials for EC2 SD, before any
+// role assumption: static access/secret keys take precedence, then a named
+// shared profile, falling back to the default credential chain.
+func ec2BaseCredentials(conf *config.EC2SDConfig) *credentials.Credentials {
+	if conf.AccessKey != "" || conf.SecretKey != "" {
+		return credentials.NewStaticCredentials(conf.AccessKey, conf.SecretKey, "")
+	}
+	if conf.Profile != "" {
+		return credentials.NewSharedCredentials("", conf.Profile)
 	}
+	return defaults.DefaultChainCredentials
 }
 
-//Break the config supplied tag filters apart and build the filters for the aws ec2 api
-func buildEc2RequestInput(tagFilters []string) *ec2.DescribeInstancesInput {
+// ec2AssumeRoleProvider builds the STS AssumeRoleProvider used to wrap the
+// base credentials when EC2SDConfig.RoleARN is set.
+func ec2AssumeRoleProvider(sess *session.Session, conf *config.EC2SDConfig) *stscreds.AssumeRoleProvider {
+	p := &stscreds.AssumeRoleProvider{
+		Client:  sts.New(sess),
+		RoleARN: conf.RoleARN,
+	}
+	if conf.ExternalID != "" {
+		p.ExternalID = aws.String(conf.ExternalID)
+	}
+	if conf.RoleSessionName != "" {
+		p.RoleSessionName = conf.RoleSessionName
+	}
+	if conf.RoleDuration > 0 {
+		p.Duration = time.Duration(conf.RoleDuration)
+	}
+	return p
+}
+
+// buildEc2RequestInput builds the ec2.DescribeInstancesInput for the given
+// structured filters, translating the legacy comma-separated TagFilters
+// into the same structure for backwards compatibility. Filter names are
+// assumed to already be validated by EC2SDConfig.UnmarshalYAML at config
+// load time.
+func buildEc2RequestInput(filters []*config.EC2Filter, tagFilters []string) *ec2.DescribeInstancesInput {
 
 	var FilterSet []*ec2.Filter
 
-	//preserves the current default behaviour (no tag filtering)
-	if len(tagFilters) == 0 {
-		return nil
+	for _, f := range filters {
+		values := make([]*string, len(f.Values))
+		for i, v := range f.Values {
+			values[i] = aws.String(v)
+		}
+		FilterSet = append(FilterSet, &ec2.Filter{
+			Name:   aws.String(f.Name),
+			Values: values,
+		})
 	}
 
-	//create a filter for each tag or tag=value found in the config
+	//create a filter for each tag or tag,value found in the legacy TagFilters config
 	for i := 0; i < len(tagFilters); i++ {
 
 		//for non-empty criteria build an ec2 filter
@@ -89,6 +162,11 @@ func buildEc2RequestInput(tagFilters []string) *ec2.DescribeInstancesInput {
 		}
 	}
 
+	//preserves the current default behaviour (no filtering)
+	if len(FilterSet) == 0 {
+		return nil
+	}
+
 	return &ec2.DescribeInstancesInput{
 		Filters: FilterSet,
 	}
@@ -157,45 +235,13 @@ func (ed *EC2Discovery) refresh() (*config.TargetGroup, error) {
 	if err := ec2s.DescribeInstancesPages(ed.ec2RequestInput, func(p *ec2.DescribeInstancesOutput, lastPage bool) bool {
 		for _, r := range p.Reservations {
 			for _, inst := range r.Instances {
-				if inst.PrivateIpAddress == nil {
-					continue
-				}
-				labels := model.LabelSet{
-					ec2LabelInstanceID: model.LabelValue(*inst.InstanceId),
-				}
-				labels[ec2LabelPrivateIP] = model.LabelValue(*inst.PrivateIpAddress)
-				addr := fmt.Sprintf("%s:%d", *inst.PrivateIpAddress, ed.port)
-				labels[model.AddressLabel] = model.LabelValue(addr)
-
-				if inst.PublicIpAddress != nil {
-					labels[ec2LabelPublicIP] = model.LabelValue(*inst.PublicIpAddress)
-					labels[ec2LabelPublicDNS] = model.LabelValue(*inst.PublicDnsName)
-				}
-
-				labels[ec2LabelAZ] = model.LabelValue(*inst.Placement.AvailabilityZone)
-
-				if inst.VpcId != nil {
-					labels[ec2LabelVPCID] = model.LabelValue(*inst.VpcId)
-
-					subnetsMap := make(map[string]struct{})
-					for _, eni := range inst.NetworkInterfaces {
-						subnetsMap[*eni.SubnetId] = struct{}{}
-					}
-					subnets := []string{}
-					for k := range subnetsMap {
-						subnets = append(subnets, k)
-					}
-					labels[ec2LabelSubnetID] = model.LabelValue(
-						subnetSeparator +
-							strings.Join(subnets, subnetSeparator) +
-							subnetSeparator)
-				}
+				labels := ec2InstanceLabels(r, inst, ed.port)
 
-				for _, t := range inst.Tags {
-					name := strutil.SanitizeLabelName(*t.Key)
-					labels[ec2LabelTag+model.LabelName(name)] = model.LabelValue(*t.Value)
+				if !ed.perInterface {
+					tg.Targets = append(tg.Targets, labels)
+					continue
 				}
-				tg.Targets = append(tg.Targets, labels)
+				tg.Targets = append(tg.Targets, ec2InterfaceTargets(labels, inst, ed.port)...)
 			}
 		}
 		return true
@@ -204,3 +250,129 @@ func (ed *EC2Discovery) refresh() (*config.TargetGroup, error) {
 	}
 	return tg, nil
 }
+
+// ec2InstanceLabels builds the common, per-instance label set shared by
+// both the per-instance and per-interface target modes.
+func ec2InstanceLabels(r *ec2.Reservation, inst *ec2.Instance, port int) model.LabelSet {
+	labels := model.LabelSet{
+		ec2LabelInstanceID: model.LabelValue(*inst.InstanceId),
+	}
+
+	if inst.State != nil && inst.State.Name != nil {
+		labels[ec2LabelInstanceState] = model.LabelValue(*inst.State.Name)
+	}
+	if inst.InstanceType != nil {
+		labels[ec2LabelInstanceType] = model.LabelValue(*inst.InstanceType)
+	}
+	if inst.InstanceLifecycle != nil {
+		labels[ec2LabelInstanceLifecycle] = model.LabelValue(*inst.InstanceLifecycle)
+	}
+	if inst.ImageId != nil {
+		labels[ec2LabelAMI] = model.LabelValue(*inst.ImageId)
+	}
+	if inst.Architecture != nil {
+		labels[ec2LabelArch] = model.LabelValue(*inst.Architecture)
+	}
+	if inst.Platform != nil {
+		labels[ec2LabelPlatform] = model.LabelValue(*inst.Platform)
+	}
+	if r.OwnerId != nil {
+		labels[ec2LabelOwnerID] = model.LabelValue(*r.OwnerId)
+	}
+	if inst.SubnetId != nil {
+		labels[ec2LabelPrimarySubnetID] = model.LabelValue(*inst.SubnetId)
+	}
+
+	// Instances without a private IP (e.g. pending or stopped) are still
+	// emitted so that relabel rules can filter on
+	// __meta_ec2_instance_state rather than relying on this discovery
+	// layer's implicit policy.
+	if inst.PrivateIpAddress != nil {
+		labels[ec2LabelPrivateIP] = model.LabelValue(*inst.PrivateIpAddress)
+		addr := fmt.Sprintf("%s:%d", *inst.PrivateIpAddress, port)
+		labels[model.AddressLabel] = model.LabelValue(addr)
+	}
+
+	if inst.PublicIpAddress != nil {
+		labels[ec2LabelPublicIP] = model.LabelValue(*inst.PublicIpAddress)
+		labels[ec2LabelPublicDNS] = model.LabelValue(*inst.PublicDnsName)
+	}
+
+	if inst.Placement != nil && inst.Placement.AvailabilityZone != nil {
+		labels[ec2LabelAZ] = model.LabelValue(*inst.Placement.AvailabilityZone)
+	}
+
+	if inst.VpcId != nil {
+		labels[ec2LabelVPCID] = model.LabelValue(*inst.VpcId)
+
+		subnetsMap := make(map[string]struct{})
+		for _, eni := range inst.NetworkInterfaces {
+			subnetsMap[*eni.SubnetId] = struct{}{}
+		}
+		subnets := []string{}
+		for k := range subnetsMap {
+			subnets = append(subnets, k)
+		}
+		labels[ec2LabelSubnetID] = model.LabelValue(
+			subnetSeparator +
+				strings.Join(subnets, subnetSeparator) +
+				subnetSeparator)
+	}
+
+	for _, t := range inst.Tags {
+		name := strutil.SanitizeLabelName(*t.Key)
+		labels[ec2LabelTag+model.LabelName(name)] = model.LabelValue(*t.Value)
+	}
+
+	return labels
+}
+
+// ec2InterfaceTargets expands an instance's base labels into one target per
+// usable network interface, so multi-homed instances (appliances, service
+// meshes, multi-VPC bridges) aren't collapsed onto a single address. If the
+// instance has no usable interfaces, it falls back to the plain
+// per-instance labels so enabling PerInterface can't make an instance
+// vanish from discovery entirely.
+func ec2InterfaceTargets(labels model.LabelSet, inst *ec2.Instance, port int) []model.LabelSet {
+	var targets []model.LabelSet
+
+	for _, eni := range inst.NetworkInterfaces {
+		if eni.NetworkInterfaceId == nil {
+			continue
+		}
+		eniLabels := labels.Clone()
+		eniLabels[ec2LabelENIID] = model.LabelValue(*eni.NetworkInterfaceId)
+
+		if eni.SubnetId != nil {
+			eniLabels[ec2LabelENISubnetID] = model.LabelValue(*eni.SubnetId)
+		}
+
+		delete(eniLabels, ec2LabelPrivateIP)
+		delete(eniLabels, model.AddressLabel)
+		if eni.PrivateIpAddress != nil {
+			eniLabels[ec2LabelENIPrivateIP] = model.LabelValue(*eni.PrivateIpAddress)
+			addr := fmt.Sprintf("%s:%d", *eni.PrivateIpAddress, port)
+			eniLabels[model.AddressLabel] = model.LabelValue(addr)
+		}
+
+		if len(eni.Ipv6Addresses) > 0 {
+			addrs := make([]string, 0, len(eni.Ipv6Addresses))
+			for _, a := range eni.Ipv6Addresses {
+				if a.Ipv6Address != nil {
+					addrs = append(addrs, *a.Ipv6Address)
+				}
+			}
+			eniLabels[ec2LabelENIIPv6Addresses] = model.LabelValue(strings.Join(addrs, subnetSeparator))
+		}
+
+		primary := eni.Attachment != nil && eni.Attachment.DeviceIndex != nil && *eni.Attachment.DeviceIndex == 0
+		eniLabels[ec2LabelENIPrimary] = model.LabelValue(fmt.Sprintf("%t", primary))
+
+		targets = append(targets, eniLabels)
+	}
+
+	if len(targets) == 0 {
+		return []model.LabelSet{labels}
+	}
+	return targets
+}
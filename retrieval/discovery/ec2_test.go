@@ -0,0 +1,205 @@
+// Copyright 2015 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestBuildEc2RequestInputNoFilters(t *testing.T) {
+	if in := buildEc2RequestInput(nil, nil); in != nil {
+		t.Errorf("expected nil DescribeInstancesInput when no filters are configured, got %+v", in)
+	}
+}
+
+func TestBuildEc2RequestInputStructuredFilters(t *testing.T) {
+	filters := []*config.EC2Filter{
+		{Name: "vpc-id", Values: []string{"vpc-1234"}},
+	}
+	in := buildEc2RequestInput(filters, nil)
+	if in == nil || len(in.Filters) != 1 {
+		t.Fatalf("expected 1 filter, got %+v", in)
+	}
+	if *in.Filters[0].Name != "vpc-id" {
+		t.Errorf("expected filter name %q, got %q", "vpc-id", *in.Filters[0].Name)
+	}
+	if *in.Filters[0].Values[0] != "vpc-1234" {
+		t.Errorf("expected filter value %q, got %q", "vpc-1234", *in.Filters[0].Values[0])
+	}
+}
+
+func TestBuildEc2RequestInputLegacyTagFilters(t *testing.T) {
+	// ec2TagFilter splits on "," only; "Name" is the tag key and anything
+	// after the first comma is a tag value, not "=".
+	in := buildEc2RequestInput(nil, []string{"Name,web", "Team"})
+	if in == nil || len(in.Filters) != 2 {
+		t.Fatalf("expected 2 filters, got %+v", in)
+	}
+	if *in.Filters[0].Name != "tag:Name" {
+		t.Errorf("expected filter name %q, got %q", "tag:Name", *in.Filters[0].Name)
+	}
+	if *in.Filters[0].Values[0] != "web" {
+		t.Errorf("expected filter value %q, got %q", "web", *in.Filters[0].Values[0])
+	}
+	if *in.Filters[1].Name != "tag:Team" {
+		t.Errorf("expected filter name %q, got %q", "tag:Team", *in.Filters[1].Name)
+	}
+	if *in.Filters[1].Values[0] != "*" {
+		t.Errorf("expected wildcard filter value, got %q", *in.Filters[1].Values[0])
+	}
+}
+
+func TestBuildEc2RequestInputCombinesFiltersAndTagFilters(t *testing.T) {
+	filters := []*config.EC2Filter{
+		{Name: "instance-state-name", Values: []string{"running"}},
+	}
+	in := buildEc2RequestInput(filters, []string{"Name,web"})
+	if in == nil || len(in.Filters) != 2 {
+		t.Fatalf("expected 2 filters, got %+v", in)
+	}
+}
+
+func TestEc2BaseCredentialsPrecedence(t *testing.T) {
+	t.Run("static keys take precedence", func(t *testing.T) {
+		creds := ec2BaseCredentials(&config.EC2SDConfig{AccessKey: "AKID", SecretKey: "SECRET"})
+		v, err := creds.Get()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if v.AccessKeyID != "AKID" || v.SecretAccessKey != "SECRET" {
+			t.Errorf("unexpected static credentials: %+v", v)
+		}
+	})
+
+	t.Run("profile is used instead of the default chain", func(t *testing.T) {
+		creds := ec2BaseCredentials(&config.EC2SDConfig{Profile: "myprofile"})
+		if creds == defaults.DefaultChainCredentials {
+			t.Error("expected shared-profile credentials, got the default credential chain")
+		}
+	})
+
+	t.Run("falls back to the default credential chain", func(t *testing.T) {
+		creds := ec2BaseCredentials(&config.EC2SDConfig{})
+		if creds != defaults.DefaultChainCredentials {
+			t.Error("expected the default credential chain")
+		}
+	})
+}
+
+func TestEc2AssumeRoleProvider(t *testing.T) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String("us-east-1")})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	conf := &config.EC2SDConfig{
+		RoleARN:         "arn:aws:iam::123456789012:role/prometheus",
+		ExternalID:      "ext-id",
+		RoleSessionName: "prometheus-ec2-sd",
+		RoleDuration:    config.DefaultEC2SDConfig.RoleDuration,
+	}
+
+	p := ec2AssumeRoleProvider(sess, conf)
+	if p.RoleARN != conf.RoleARN {
+		t.Errorf("expected RoleARN %q, got %q", conf.RoleARN, p.RoleARN)
+	}
+	if p.RoleSessionName != conf.RoleSessionName {
+		t.Errorf("expected RoleSessionName %q, got %q", conf.RoleSessionName, p.RoleSessionName)
+	}
+	if p.ExternalID == nil || *p.ExternalID != conf.ExternalID {
+		t.Errorf("expected ExternalID %q, got %v", conf.ExternalID, p.ExternalID)
+	}
+	wantDuration := time.Duration(config.DefaultEC2SDConfig.RoleDuration)
+	if p.Duration != wantDuration {
+		t.Errorf("expected default role duration %s, got %s", wantDuration, p.Duration)
+	}
+	var _ credentials.Provider = p
+}
+
+func TestEc2InstanceLabelsWithoutPrivateIP(t *testing.T) {
+	r := &ec2.Reservation{}
+	inst := &ec2.Instance{
+		InstanceId: aws.String("i-1234"),
+		State:      &ec2.InstanceState{Name: aws.String("pending")},
+	}
+
+	labels := ec2InstanceLabels(r, inst, 9100)
+	if labels[ec2LabelInstanceState] != "pending" {
+		t.Errorf("expected instance state label %q, got %q", "pending", labels[ec2LabelInstanceState])
+	}
+	if _, ok := labels[ec2LabelPrivateIP]; ok {
+		t.Errorf("expected no private IP label, got %q", labels[ec2LabelPrivateIP])
+	}
+	if _, ok := labels[model.AddressLabel]; ok {
+		t.Errorf("expected no address label, got %q", labels[model.AddressLabel])
+	}
+}
+
+func TestEc2InterfaceTargetsOnePerENI(t *testing.T) {
+	inst := &ec2.Instance{
+		NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+			{
+				NetworkInterfaceId: aws.String("eni-1"),
+				SubnetId:           aws.String("subnet-1"),
+				PrivateIpAddress:   aws.String("10.0.0.1"),
+				Attachment:         &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(0)},
+			},
+			{
+				NetworkInterfaceId: aws.String("eni-2"),
+				SubnetId:           aws.String("subnet-2"),
+				PrivateIpAddress:   aws.String("10.0.1.1"),
+				Attachment:         &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(1)},
+			},
+		},
+	}
+	base := model.LabelSet{ec2LabelInstanceID: "i-1234"}
+
+	targets := ec2InterfaceTargets(base, inst, 9100)
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(targets))
+	}
+	if targets[0][ec2LabelENIID] != "eni-1" || targets[0][model.AddressLabel] != "10.0.0.1:9100" {
+		t.Errorf("unexpected labels for first ENI: %v", targets[0])
+	}
+	if targets[0][ec2LabelENIPrimary] != "true" {
+		t.Errorf("expected first ENI to be marked primary, got %v", targets[0][ec2LabelENIPrimary])
+	}
+	if targets[1][ec2LabelENIPrimary] != "false" {
+		t.Errorf("expected second ENI not to be marked primary, got %v", targets[1][ec2LabelENIPrimary])
+	}
+}
+
+func TestEc2InterfaceTargetsFallsBackWithoutENIs(t *testing.T) {
+	inst := &ec2.Instance{}
+	base := model.LabelSet{
+		ec2LabelInstanceID: "i-1234",
+		ec2LabelPrivateIP:  "10.0.0.1",
+	}
+
+	targets := ec2InterfaceTargets(base, inst, 9100)
+	if len(targets) != 1 {
+		t.Fatalf("expected fallback to the plain per-instance target, got %d targets", len(targets))
+	}
+	if targets[0][ec2LabelInstanceID] != "i-1234" {
+		t.Errorf("expected fallback target to retain instance labels, got %v", targets[0])
+	}
+}